@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// AdminSet tracks which fingerprints hold the admin role. It is
+// initialised from a config file and can be extended at runtime via
+// Promote (the /op command).
+type AdminSet struct {
+	mutex sync.Mutex
+	path  string
+	admin map[string]bool
+}
+
+// LoadAdminSet reads the list of admin fingerprints from path. A
+// missing file means no admins are configured yet.
+func LoadAdminSet(path string) (*AdminSet, error) {
+	set := &AdminSet{path: path, admin: make(map[string]bool)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return set, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading admin config: %w", err)
+	}
+
+	var fingerprints []string
+	if err := json.Unmarshal(data, &fingerprints); err != nil {
+		return nil, fmt.Errorf("parsing admin config: %w", err)
+	}
+	for _, fp := range fingerprints {
+		set.admin[fp] = true
+	}
+	return set, nil
+}
+
+// IsAdmin reports whether fingerprint holds the admin role.
+func (s *AdminSet) IsAdmin(fingerprint string) bool {
+	if fingerprint == "" {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.admin[fingerprint]
+}
+
+// Promote grants the admin role to fingerprint and persists it to the
+// config file so it survives a restart.
+func (s *AdminSet) Promote(fingerprint string) error {
+	if fingerprint == "" {
+		return fmt.Errorf("cannot promote a connection without a public key")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.admin[fingerprint] = true
+
+	fingerprints := make([]string, 0, len(s.admin))
+	for fp := range s.admin {
+		fingerprints = append(fingerprints, fp)
+	}
+
+	data, err := json.MarshalIndent(fingerprints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding admin config: %w", err)
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// BanList is an in-memory set of banned keys (a fingerprint, a
+// nickname, or an IP address) with TTL eviction. A zero-value TTL at
+// Ban time means the ban never expires until the process restarts.
+type BanList struct {
+	mutex  sync.Mutex
+	expiry map[string]time.Time // key -> zero time means "forever"
+}
+
+// NewBanList returns an empty ban list.
+func NewBanList() *BanList {
+	return &BanList{expiry: make(map[string]time.Time)}
+}
+
+// Ban bans key for the given duration. A duration of zero bans forever.
+func (b *BanList) Ban(key string, duration time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if duration <= 0 {
+		b.expiry[key] = time.Time{}
+		return
+	}
+	b.expiry[key] = time.Now().Add(duration)
+}
+
+// Banned reports whether key is currently banned, evicting it first if
+// its ban has expired.
+func (b *BanList) Banned(key string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	until, ok := b.expiry[key]
+	if !ok {
+		return false
+	}
+	if until.IsZero() {
+		return true
+	}
+	if time.Now().After(until) {
+		delete(b.expiry, key)
+		return false
+	}
+	return true
+}
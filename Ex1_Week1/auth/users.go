@@ -0,0 +1,66 @@
+// Package auth identifies chat users by their SSH public key fingerprint
+// and keeps track of admin privileges and bans.
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// UserStore maps SSH public key fingerprints to stable nicknames so a
+// returning client keeps the same identity even if it asks for a
+// different nickname mid-session.
+type UserStore struct {
+	mutex sync.Mutex
+	path  string
+	users map[string]string // fingerprint -> nickname
+}
+
+// LoadUserStore reads the fingerprint->nickname mapping from path. A
+// missing file is treated as an empty store so a fresh checkout works
+// without any setup.
+func LoadUserStore(path string) (*UserStore, error) {
+	store := &UserStore{path: path, users: make(map[string]string)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading user store: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &store.users); err != nil {
+		return nil, fmt.Errorf("parsing user store: %w", err)
+	}
+	return store, nil
+}
+
+// Nickname returns the nickname registered for fingerprint, if any.
+func (s *UserStore) Nickname(fingerprint string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	nickname, ok := s.users[fingerprint]
+	return nickname, ok
+}
+
+// Register records that fingerprint is now known by nickname and
+// persists the store to disk.
+func (s *UserStore) Register(fingerprint, nickname string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.users[fingerprint] = nickname
+	return s.save()
+}
+
+func (s *UserStore) save() error {
+	data, err := json.MarshalIndent(s.users, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding user store: %w", err)
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
@@ -0,0 +1,74 @@
+// Package bouncer implements soju-style connection bouncing: a fixed
+// capacity ring buffer of messages that survives a client's sessions
+// disconnecting, so a reconnecting client can replay what it missed.
+package bouncer
+
+import "sync"
+
+// Ring is a fixed-capacity circular buffer of messages, each tagged
+// with a monotonically increasing sequence number so a client can ask
+// for everything "since" the last one it saw.
+type Ring struct {
+	mutex    sync.Mutex
+	messages []string
+	seqs     []uint64
+	next     int // index in messages/seqs to write next
+	size     int // number of valid entries (<= cap(messages))
+	lastSeq  uint64
+}
+
+// NewRing returns an empty ring holding at most capacity messages.
+func NewRing(capacity int) *Ring {
+	return &Ring{
+		messages: make([]string, capacity),
+		seqs:     make([]uint64, capacity),
+	}
+}
+
+// Push appends message to the ring, evicting the oldest entry once
+// full, and returns its sequence number.
+func (r *Ring) Push(message string) uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.lastSeq++
+	r.messages[r.next] = message
+	r.seqs[r.next] = r.lastSeq
+	r.next = (r.next + 1) % len(r.messages)
+	if r.size < len(r.messages) {
+		r.size++
+	}
+	return r.lastSeq
+}
+
+// LastSeq returns the sequence number of the most recently pushed
+// message, or 0 if the ring is empty.
+func (r *Ring) LastSeq() uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.lastSeq
+}
+
+// Since returns every buffered message with a sequence number greater
+// than since, oldest first, along with the sequence number to pass
+// next time to resume exactly here. Messages evicted before the
+// caller could read them are silently skipped, same as any bounded
+// ring buffer.
+func (r *Ring) Since(since uint64) ([]string, uint64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.size == 0 {
+		return nil, r.lastSeq
+	}
+
+	oldest := (r.next - r.size + len(r.messages)) % len(r.messages)
+	var out []string
+	for i := 0; i < r.size; i++ {
+		idx := (oldest + i) % len(r.messages)
+		if r.seqs[idx] > since {
+			out = append(out, r.messages[idx])
+		}
+	}
+	return out, r.lastSeq
+}
@@ -0,0 +1,74 @@
+package bouncer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRingSince(t *testing.T) {
+	r := NewRing(10)
+
+	seq1 := r.Push("one")
+	seq2 := r.Push("two")
+	r.Push("three")
+
+	lines, last := r.Since(seq1)
+	if !reflect.DeepEqual(lines, []string{"two", "three"}) {
+		t.Fatalf("Since(seq1) lines = %v, want [two three]", lines)
+	}
+	if last != seq2+1 {
+		t.Fatalf("Since(seq1) last = %d, want %d", last, seq2+1)
+	}
+
+	lines, last = r.Since(last)
+	if lines != nil {
+		t.Fatalf("Since(lastSeq) lines = %v, want none", lines)
+	}
+	if last != seq2+1 {
+		t.Fatalf("Since(lastSeq) last = %d, want unchanged %d", last, seq2+1)
+	}
+}
+
+func TestRingSinceEmpty(t *testing.T) {
+	r := NewRing(4)
+	lines, last := r.Since(0)
+	if lines != nil || last != 0 {
+		t.Fatalf("Since on empty ring = (%v, %d), want (nil, 0)", lines, last)
+	}
+}
+
+func TestRingEviction(t *testing.T) {
+	r := NewRing(3)
+
+	for i := 0; i < 5; i++ {
+		r.Push(string(rune('a' + i)))
+	}
+
+	// Capacity 3, 5 pushes: "a" and "b" are evicted, leaving c, d, e
+	// with sequence numbers 3, 4, 5.
+	lines, last := r.Since(0)
+	if !reflect.DeepEqual(lines, []string{"c", "d", "e"}) {
+		t.Fatalf("Since(0) after eviction = %v, want [c d e]", lines)
+	}
+	if last != 5 {
+		t.Fatalf("LastSeq after eviction = %d, want 5", last)
+	}
+
+	// Asking for messages since an already-evicted sequence number
+	// should silently skip them rather than error.
+	lines, _ = r.Since(1)
+	if !reflect.DeepEqual(lines, []string{"c", "d", "e"}) {
+		t.Fatalf("Since(1) after eviction = %v, want [c d e]", lines)
+	}
+}
+
+func TestRingLastSeq(t *testing.T) {
+	r := NewRing(2)
+	if got := r.LastSeq(); got != 0 {
+		t.Fatalf("LastSeq on empty ring = %d, want 0", got)
+	}
+	r.Push("x")
+	if got := r.LastSeq(); got != 1 {
+		t.Fatalf("LastSeq after one push = %d, want 1", got)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// deadlineConn is satisfied by net.Conn (and notably not by an SSH
+// channel, which has no write deadline); safeConn uses it opportunistically.
+type deadlineConn interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// safeConn wraps a Conn so concurrent writes from different goroutines
+// (broadcastMessage fans out from many goroutines at once) don't
+// interleave their bytes, and so a client that stops reading can't
+// block a broadcaster forever: every Write gets its own deadline
+// whenever the underlying connection supports one.
+type safeConn struct {
+	Conn
+	mu           sync.Mutex
+	writeTimeout time.Duration
+}
+
+// newSafeConn wraps conn so its writes are serialized and bounded by
+// writeTimeout. A non-positive timeout disables the deadline.
+func newSafeConn(conn Conn, writeTimeout time.Duration) *safeConn {
+	return &safeConn{Conn: conn, writeTimeout: writeTimeout}
+}
+
+func (c *safeConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.writeTimeout > 0 {
+		if d, ok := c.Conn.(deadlineConn); ok {
+			d.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+		}
+	}
+	return c.Conn.Write(b)
+}
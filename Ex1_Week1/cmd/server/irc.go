@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/IlyaShelestov/Ex1_Week1/message"
+)
+
+// serverName is used as the prefix on numeric replies and as the host
+// part of a client's own IRC source.
+const serverName = "chat-server"
+
+// handleIRCLine parses one line as an IRC message and dispatches it to
+// the matching command handler. Unknown commands and parse errors are
+// reported back to the client, same as a real ircd would.
+//
+// Moderation (/kick, /ban, /op) has no IRC-wire equivalent the client
+// side of this server speaks (no KICK/MODE support), so those are
+// handled as the same server-specific slash commands the simple
+// protocol uses, checked before the line is parsed as IRC.
+func handleIRCLine(conn Conn, nickname *string, line string) {
+	if adminCommand(conn, line) {
+		return
+	}
+
+	msg, err := message.Parse(line)
+	if err != nil {
+		return
+	}
+
+	switch msg.Command {
+	case "NICK":
+		ircNick(conn, nickname, msg)
+	case "USER":
+		ircUser(conn, *nickname, msg)
+	case "JOIN":
+		ircJoin(conn, *nickname, msg)
+	case "PART":
+		ircPart(conn, *nickname, msg)
+	case "PRIVMSG":
+		ircPrivmsg(conn, *nickname, msg, false)
+	case "NOTICE":
+		ircPrivmsg(conn, *nickname, msg, true)
+	case "NAMES":
+		ircNames(conn, msg)
+	case "WHO":
+		ircWho(conn, msg)
+	case "QUIT":
+		conn.Close()
+	case "PING":
+		sendIRC(conn, message.Message{Command: "PONG", Params: []string{serverName}, Trailing: firstParam(msg)})
+	case "PONG":
+		// no-op: clients PONG in response to our PING keepalives.
+	case "WALLOPS":
+		ircWallops(conn, *nickname, msg)
+	default:
+		sendNumeric(conn, "421", *nickname, msg.Command, "Unknown command")
+	}
+}
+
+func firstParam(msg message.Message) string {
+	if len(msg.Params) == 0 {
+		return ""
+	}
+	return msg.Params[0]
+}
+
+func sendIRC(conn Conn, msg message.Message) {
+	if msg.Prefix == "" {
+		msg.Prefix = serverName
+	}
+	conn.Write([]byte(msg.String() + "\r\n"))
+}
+
+func sendNumeric(conn Conn, numeric, nick string, params ...string) {
+	sendIRC(conn, message.Message{
+		Command:  numeric,
+		Params:   append([]string{nick}, params[:len(params)-1]...),
+		Trailing: params[len(params)-1],
+	})
+}
+
+func clientSource(nickname string) string {
+	return fmt.Sprintf("%s!%s@%s", nickname, nickname, serverName)
+}
+
+func ircNick(conn Conn, nickname *string, msg message.Message) {
+	if len(msg.Params) != 1 {
+		sendNumeric(conn, "431", *nickname, "No nickname given")
+		return
+	}
+	changeNickname(conn, nickname, msg.Params[0])
+}
+
+func ircUser(conn Conn, nickname string, msg message.Message) {
+	// USER only carries the client's local username/realname, which
+	// this server doesn't otherwise track; just complete the
+	// handshake with a welcome reply.
+	sendNumeric(conn, "001", nickname, fmt.Sprintf("Welcome to %s, %s", serverName, nickname))
+}
+
+func ircJoin(conn Conn, nickname string, msg message.Message) {
+	user, ok := userOf(conn)
+	if !ok {
+		return
+	}
+
+	for _, channel := range strings.Split(firstParam(msg), ",") {
+		if !message.IsChannel(channel) {
+			sendNumeric(conn, "403", nickname, channel, "No such channel")
+			continue
+		}
+
+		// Broadcast to the existing members before registering user, so
+		// the fan-out (skip=nil) can't also deliver the JOIN to the
+		// joiner itself; sendIRC below is its one and only copy.
+		joinMsg := message.Message{Prefix: clientSource(nickname), Command: "JOIN", Params: []string{channel}}
+		broadcastToChannel(channel, joinMsg.String()+"\r\n", nil)
+
+		mutex.Lock()
+		members, ok := channels[channel]
+		if !ok {
+			members = make(map[*User]bool)
+			channels[channel] = members
+		}
+		members[user] = true
+		mutex.Unlock()
+
+		sendIRC(conn, joinMsg)
+		ircNames(conn, message.Message{Params: []string{channel}})
+	}
+}
+
+func ircPart(conn Conn, nickname string, msg message.Message) {
+	user, ok := userOf(conn)
+	if !ok {
+		return
+	}
+
+	for _, channel := range strings.Split(firstParam(msg), ",") {
+		mutex.Lock()
+		if members, ok := channels[channel]; ok {
+			delete(members, user)
+		}
+		mutex.Unlock()
+
+		partMsg := message.Message{Prefix: clientSource(nickname), Command: "PART", Params: []string{channel}}
+		sendIRC(conn, partMsg)
+		broadcastToChannel(channel, partMsg.String()+"\r\n", nil)
+	}
+}
+
+func ircPrivmsg(conn Conn, nickname string, msg message.Message, notice bool) {
+	if len(msg.Params) == 0 {
+		return
+	}
+	target := msg.Params[0]
+	command := "PRIVMSG"
+	if notice {
+		command = "NOTICE"
+	}
+
+	// The body is everything after the target: Parse only fills in
+	// Trailing when the client sent it ":"-prefixed, so a single-word
+	// body with no colon would otherwise be lost.
+	text := strings.Join(msg.Params[1:], " ")
+	out := message.Message{Prefix: clientSource(nickname), Command: command, Params: []string{target, text}, Trailing: text}
+	line := out.String() + "\r\n"
+
+	if message.IsChannel(target) {
+		if !notice {
+			if _, err := histories.Append(target, out.String()); err != nil {
+				log.Printf("Error appending to channel history: %s", err)
+			}
+		}
+		broadcastToChannel(target, line, conn)
+		return
+	}
+
+	if recipient, ok := findUserByNickname(target); ok {
+		recipient.write(line)
+	} else if !notice {
+		sendNumeric(conn, "401", nickname, target, "No such nick/channel")
+	}
+}
+
+func ircNames(conn Conn, msg message.Message) {
+	channel := firstParam(msg)
+
+	mutex.Lock()
+	members := make([]*User, 0, len(channels[channel]))
+	for member := range channels[channel] {
+		members = append(members, member)
+	}
+	mutex.Unlock()
+
+	var nicks []string
+	for _, member := range members {
+		member.mu.Lock()
+		nicks = append(nicks, member.Nickname)
+		member.mu.Unlock()
+	}
+
+	sendIRC(conn, message.Message{Command: "353", Params: []string{"=", channel}, Trailing: strings.Join(nicks, " ")})
+	sendIRC(conn, message.Message{Command: "366", Params: []string{channel}, Trailing: "End of /NAMES list"})
+}
+
+func ircWho(conn Conn, msg message.Message) {
+	channel := firstParam(msg)
+
+	mutex.Lock()
+	members := make([]*User, 0, len(channels[channel]))
+	for member := range channels[channel] {
+		members = append(members, member)
+	}
+	mutex.Unlock()
+
+	for _, member := range members {
+		member.mu.Lock()
+		nick := member.Nickname
+		member.mu.Unlock()
+		sendIRC(conn, message.Message{Command: "352", Params: []string{channel, nick, serverName, serverName, nick, "H"}, Trailing: "0 " + nick})
+	}
+	sendIRC(conn, message.Message{Command: "315", Params: []string{channel}, Trailing: "End of /WHO list"})
+}
+
+// ircDisconnect announces a user's disconnect as a QUIT, but only
+// once none of its sessions are still attached: losing one of
+// several bounced sessions isn't really leaving, so channel
+// membership (keyed by User, not by the Conn that just dropped) and
+// the QUIT notice only apply once the user has no sessions left.
+func ircDisconnect(conn Conn, nickname string) {
+	user, ok := userOf(conn)
+	if !ok || len(user.sessions()) > 1 {
+		return
+	}
+
+	mutex.Lock()
+	var memberOf []string
+	for channel, members := range channels {
+		if members[user] {
+			memberOf = append(memberOf, channel)
+			delete(members, user)
+		}
+	}
+	mutex.Unlock()
+
+	quitMsg := message.Message{Prefix: clientSource(nickname), Command: "QUIT", Trailing: "Connection closed"}
+	line := quitMsg.String() + "\r\n"
+	for _, channel := range memberOf {
+		broadcastToChannel(channel, line, nil)
+	}
+}
+
+func ircWallops(conn Conn, nickname string, msg message.Message) {
+	if !requireAdmin(conn) {
+		return
+	}
+	text := strings.Join(msg.Params, " ")
+	out := message.Message{Prefix: clientSource(nickname), Command: "WALLOPS", Params: []string{text}, Trailing: text}
+	broadcastMessage(out.String()+"\r\n", conn)
+}
+
+// broadcastToChannel delivers line to every member of channel except
+// skip (pass nil to include the sender), the same way broadcastMessage
+// does for the simple protocol: through User.write so it lands in the
+// Ring too, not just on the live Conns, so a member who was away when
+// the message went out still has it to replay on reconnect.
+func broadcastToChannel(channel, line string, skip Conn) {
+	mutex.Lock()
+	members := make([]*User, 0, len(channels[channel]))
+	for member := range channels[channel] {
+		members = append(members, member)
+	}
+	mutex.Unlock()
+
+	for _, member := range members {
+		member.writeExcept(line, skip)
+	}
+}
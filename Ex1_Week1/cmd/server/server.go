@@ -1,227 +1,502 @@
-package main
-
-import (
-	"bufio"
-	"fmt"
-	"log"
-	"net"
-	"os"
-	"strings"
-	"sync"
-	"time"
-)
-
-const (
-	CONN_PORT = ":9090"
-	CONN_TYPE = "tcp"
-)
-
-var (
-	clients       = make(map[net.Conn]string)
-	addr          = make(map[net.Conn]string)
-	mutex         sync.Mutex
-	historyLog    = "history.log"
-	tasks         = make(map[string]Task)
-	taskIDCounter int
-)
-
-type Task struct {
-	ID          string
-	Description string
-	Owner       string
-}
-
-func handleConnection(conn net.Conn) {
-	nickname := "Anonymous"
-
-	mutex.Lock()
-	clients[conn] = nickname
-	addr[conn] = conn.RemoteAddr().String()
-	mutex.Unlock()
-
-	defer func() {
-		mutex.Lock()
-		delete(clients, conn)
-		delete(addr, conn)
-		mutex.Unlock()
-		conn.Close()
-	}()
-
-	logFile, err := os.OpenFile(historyLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Println("Error opening history log file:", err)
-		return
-	}
-	defer logFile.Close()
-
-	log.Printf("Client %s (%s) connected.", addr[conn], nickname)
-
-	for {
-		netData, err := bufio.NewReader(conn).ReadString('\n')
-		if err != nil {
-			log.Printf("Client %s (%s) disconnected.", addr[conn], nickname)
-			broadcastMessage(fmt.Sprintf("%s disconnected from the chat!\n", nickname), conn)
-			break
-		}
-
-		handleCommands(conn, &nickname, strings.TrimSpace(string(netData)), logFile)
-	}
-}
-
-func handleCommands(conn net.Conn, nickname *string, message string, logFile *os.File) {
-	if strings.HasPrefix(message, "/quit") {
-		conn.Write([]byte("Goodbye!\n"))
-		conn.Close()
-	} else if strings.HasPrefix(message, "/history") {
-		sendHistory(conn)
-	} else if strings.HasPrefix(message, "/nickname") {
-		parts := strings.SplitN(message, " ", 2)
-		if len(parts) == 2 {
-			changeNickname(conn, nickname, parts[1])
-		}
-	} else if strings.HasPrefix(message, "/users") {
-		sendUsersList(conn)
-	} else if strings.HasPrefix(message, "/task add") {
-		parts := strings.SplitN(message, " ", 3)
-		if len(parts) == 3 {
-			addTask(conn, *nickname, parts[2])
-		}
-	} else if strings.HasPrefix(message, "/task list") {
-		listTasks(conn)
-	} else if strings.HasPrefix(message, "/task delete") {
-		parts := strings.SplitN(message, " ", 3)
-		if len(parts) == 3 {
-			deleteTask(conn, parts[2])
-		}
-	} else {
-		logMessage(*nickname, message, logFile)
-		response := fmt.Sprintf("%s: %s\n", *nickname, message)
-		broadcastMessage(response, conn)
-	}
-}
-
-func sendUsersList(conn net.Conn) {
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	var users []string
-	for _, nickname := range clients {
-		users = append(users, nickname)
-	}
-
-	usersList := strings.Join(users, ", ")
-	message := fmt.Sprintf("Connected users: %s\n", usersList)
-	conn.Write([]byte(message))
-}
-
-func changeNickname(conn net.Conn, nickname *string, newNickname string) {
-	oldNickname := *nickname
-	*nickname = newNickname
-
-	mutex.Lock()
-	clients[conn] = newNickname
-	mutex.Unlock()
-
-	conn.Write([]byte(fmt.Sprintf("Nickname changed to %s\n", newNickname)))
-
-	log.Printf("Client %s (%s) changed nickname to %s.", addr[conn], oldNickname, newNickname)
-	broadcastMessage(fmt.Sprintf("'%s' changed nickname to '%s'\n", oldNickname, newNickname), conn)
-}
-
-func sendHistory(conn net.Conn) {
-	file, err := os.Open(historyLog)
-	if err != nil {
-		conn.Write([]byte("Error reading history.\n"))
-		return
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		message := scanner.Text() + "\n"
-		_, err := conn.Write([]byte(message))
-		if err != nil {
-			log.Printf("Error sending history to client: %s", err)
-			break
-		}
-		time.Sleep(10 * time.Millisecond)
-	}
-
-	if err := scanner.Err(); err != nil {
-		log.Printf("Error reading from history file: %s", err)
-		conn.Write([]byte("Error occurred while reading history.\n"))
-	}
-}
-
-func logMessage(nickname string, message string, logFile *os.File) {
-	currentTime := time.Now().Format(time.RFC1123)
-	logEntry := fmt.Sprintf("%s: %s - %s\n", currentTime, nickname, message)
-	logFile.WriteString(logEntry)
-}
-
-func broadcastMessage(message string, sender net.Conn) {
-	mutex.Lock()
-	defer mutex.Unlock()
-	for conn := range clients {
-		if conn != sender {
-			conn.Write([]byte(message))
-		}
-	}
-}
-
-func addTask(conn net.Conn, owner, description string) {
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	taskIDCounter++
-	taskID := fmt.Sprintf("%d", taskIDCounter)
-	tasks[taskID] = Task{ID: taskID, Description: description, Owner: owner}
-
-	conn.Write([]byte(fmt.Sprintf("Task added with ID %s\n", taskID)))
-}
-
-func listTasks(conn net.Conn) {
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	var taskDescriptions []string
-	for _, task := range tasks {
-		taskDescriptions = append(taskDescriptions, fmt.Sprintf("ID: %s, Owner: %s, Description: %s", task.ID, task.Owner, task.Description))
-	}
-
-	if len(taskDescriptions) == 0 {
-		conn.Write([]byte("No tasks found.\n"))
-	} else {
-		conn.Write([]byte(strings.Join(taskDescriptions, "; ") + "\n"))
-	}
-}
-
-func deleteTask(conn net.Conn, taskID string) {
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	if _, ok := tasks[taskID]; ok {
-		delete(tasks, taskID)
-		conn.Write([]byte("Task deleted successfully.\n"))
-	} else {
-		conn.Write([]byte("Task not found.\n"))
-	}
-}
-
-func main() {
-	listener, err := net.Listen(CONN_TYPE, CONN_PORT)
-	if err != nil {
-		log.Fatal("Error starting TCP server:", err)
-	}
-	defer listener.Close()
-	log.Println("Server listening on", CONN_PORT)
-
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			log.Println("Error accepting connection:", err)
-			continue
-		}
-		go handleConnection(conn)
-	}
-}
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IlyaShelestov/Ex1_Week1/auth"
+	"github.com/IlyaShelestov/Ex1_Week1/history"
+	"github.com/IlyaShelestov/Ex1_Week1/storage"
+)
+
+const (
+	CONN_PORT = ":9090"
+	CONN_TYPE = "tcp"
+
+	// globalChannel is where plain (non-IRC) chat messages are logged,
+	// since the simple protocol has no notion of channels.
+	globalChannel = "global"
+)
+
+var (
+	mutex sync.Mutex
+
+	users     *auth.UserStore
+	admins    *auth.AdminSet
+	bans      = auth.NewBanList()
+	histories *history.Store
+	watcher   *history.Watcher
+	taskStore *storage.Store
+
+	// channels holds per-channel membership for the IRC protocol mode,
+	// keyed by channel name (including its "#" prefix) and then by
+	// User rather than Conn, so a member keeps their membership (and
+	// keeps receiving channel traffic into their Ring) across a
+	// session dropping, same as the bouncer model everywhere else.
+	channels = make(map[string]map[*User]bool)
+
+	// protocolMode selects the wire format handleConnection speaks:
+	// "simple" for the original ad-hoc "/command arg" lines, "irc" for
+	// RFC 1459-style messages. Set once from a flag in main.
+	protocolMode = "simple"
+
+	// writeTimeout bounds how long a single Write to a client may block,
+	// so a slow reader on one connection can't stall a broadcaster that
+	// is fanning a message out to everyone else. Set once from a flag in
+	// main; see safeConn.
+	writeTimeout = 5 * time.Second
+)
+
+// Conn is the subset of net.Conn that both raw TCP connections and SSH
+// channels can satisfy, so handleConnection doesn't care which
+// transport a client came in over.
+type Conn interface {
+	Read(b []byte) (int, error)
+	Write(b []byte) (int, error)
+	Close() error
+	RemoteAddr() net.Addr
+}
+
+func handleConnection(conn Conn, fingerprint string) {
+	nickname := "Anonymous"
+	if fingerprint != "" {
+		if existing, ok := users.Nickname(fingerprint); ok {
+			nickname = existing
+		}
+	}
+
+	attachSession(conn, fingerprint, nickname)
+
+	defer func() {
+		detachSession(conn)
+		conn.Close()
+	}()
+
+	log.Printf("Client %s (%s) connected.", conn.RemoteAddr(), nickname)
+
+	reader := bufio.NewReader(conn)
+	for {
+		netData, err := reader.ReadString('\n')
+		if err != nil {
+			log.Printf("Client %s (%s) disconnected.", conn.RemoteAddr(), nickname)
+			if protocolMode == "irc" {
+				ircDisconnect(conn, nickname)
+			} else {
+				broadcastMessage(fmt.Sprintf("%s disconnected from the chat!\n", nickname), conn)
+			}
+			break
+		}
+
+		line := strings.TrimSpace(string(netData))
+		if protocolMode == "irc" {
+			handleIRCLine(conn, &nickname, line)
+		} else {
+			handleCommands(conn, &nickname, line)
+		}
+	}
+}
+
+func handleCommands(conn Conn, nickname *string, message string) {
+	if strings.HasPrefix(message, "/quit") {
+		conn.Write([]byte("Goodbye!\n"))
+		conn.Close()
+	} else if strings.HasPrefix(message, "/history") {
+		sendHistory(conn, strings.Fields(message)[1:])
+	} else if strings.HasPrefix(message, "/subscribe") {
+		parts := strings.SplitN(message, " ", 2)
+		if len(parts) == 2 {
+			subscribeChannel(conn, parts[1])
+		}
+	} else if strings.HasPrefix(message, "/attach") {
+		parts := strings.SplitN(message, " ", 2)
+		arg := ""
+		if len(parts) == 2 {
+			arg = strings.TrimSpace(parts[1])
+		}
+		attachUser(conn, arg)
+	} else if strings.HasPrefix(message, "/nickname") {
+		parts := strings.SplitN(message, " ", 2)
+		if len(parts) == 2 {
+			changeNickname(conn, nickname, parts[1])
+		}
+	} else if strings.HasPrefix(message, "/users") {
+		sendUsersList(conn)
+	} else if adminCommand(conn, message) {
+		// handled
+	} else if strings.HasPrefix(message, "/task") {
+		handleTaskCommand(conn, *nickname, strings.Fields(message)[1:])
+	} else {
+		logMessage(*nickname, message)
+		response := fmt.Sprintf("%s: %s\n", *nickname, message)
+		broadcastMessage(response, conn)
+	}
+}
+
+// adminCommand handles the /kick, /ban and /op moderation commands,
+// shared by both protocol modes since they have no IRC-wire
+// equivalent (no KICK/MODE support), and reports whether message was
+// one of them.
+func adminCommand(conn Conn, message string) bool {
+	if strings.HasPrefix(message, "/kick") {
+		if parts := strings.SplitN(message, " ", 2); len(parts) == 2 {
+			kickUser(conn, parts[1])
+		}
+		return true
+	}
+	if strings.HasPrefix(message, "/ban") {
+		if parts := strings.SplitN(message, " ", 3); len(parts) >= 2 {
+			var duration string
+			if len(parts) == 3 {
+				duration = parts[2]
+			}
+			banTarget(conn, parts[1], duration)
+		}
+		return true
+	}
+	if strings.HasPrefix(message, "/op") {
+		if parts := strings.SplitN(message, " ", 2); len(parts) == 2 {
+			opUser(conn, parts[1])
+		}
+		return true
+	}
+	return false
+}
+
+// requireAdmin writes a refusal to conn and returns false unless the
+// connection was authenticated with an admin's public key.
+func requireAdmin(conn Conn) bool {
+	var fingerprint string
+	if user, ok := userOf(conn); ok {
+		user.mu.Lock()
+		fingerprint = user.Fingerprint
+		user.mu.Unlock()
+	}
+
+	if admins == nil || !admins.IsAdmin(fingerprint) {
+		conn.Write([]byte("You are not allowed to do that.\n"))
+		return false
+	}
+	return true
+}
+
+func kickUser(conn Conn, target string) {
+	if !requireAdmin(conn) {
+		return
+	}
+
+	victim, ok := findUserByNickname(target)
+	if !ok {
+		conn.Write([]byte(fmt.Sprintf("No such user: %s\n", target)))
+		return
+	}
+
+	for _, session := range victim.sessions() {
+		session.conn.Write([]byte("You have been kicked from the chat.\n"))
+		session.conn.Close()
+	}
+	broadcastMessage(fmt.Sprintf("%s was kicked.\n", target), conn)
+}
+
+func banTarget(conn Conn, target, durationArg string) {
+	if !requireAdmin(conn) {
+		return
+	}
+
+	duration := time.Duration(0)
+	if durationArg != "" {
+		parsed, err := time.ParseDuration(durationArg)
+		if err != nil {
+			conn.Write([]byte(fmt.Sprintf("Invalid duration: %s\n", durationArg)))
+			return
+		}
+		duration = parsed
+	}
+
+	// Ban keys have to be something accept-time checks actually look at
+	// (a fingerprint or a remote host, per bans.Banned's callers in
+	// main and serveSSH) — banning the literal nickname string would
+	// let the victim straight back in under the same identity.
+	victim, ok := findUserByNickname(target)
+	if !ok {
+		conn.Write([]byte(fmt.Sprintf("No such user: %s\n", target)))
+		return
+	}
+
+	victim.mu.Lock()
+	fingerprint := victim.Fingerprint
+	victim.mu.Unlock()
+
+	sessions := victim.sessions()
+	if fingerprint == "" && len(sessions) == 0 {
+		// No stable identity to ban: an anonymous user with no active
+		// session has no fingerprint and no remote host left to key on.
+		conn.Write([]byte(fmt.Sprintf("Cannot ban %s: no fingerprint or active session to ban.\n", target)))
+		return
+	}
+
+	if fingerprint != "" {
+		bans.Ban(fingerprint, duration)
+	}
+	for _, session := range sessions {
+		bans.Ban(remoteHost(session.conn.RemoteAddr()), duration)
+		session.conn.Write([]byte("You have been banned from the chat.\n"))
+		session.conn.Close()
+	}
+
+	conn.Write([]byte(fmt.Sprintf("Banned %s\n", target)))
+}
+
+func opUser(conn Conn, target string) {
+	if !requireAdmin(conn) {
+		return
+	}
+
+	victim, ok := findUserByNickname(target)
+	if !ok {
+		conn.Write([]byte(fmt.Sprintf("No such user: %s\n", target)))
+		return
+	}
+
+	victim.mu.Lock()
+	fingerprint := victim.Fingerprint
+	victim.mu.Unlock()
+
+	if err := admins.Promote(fingerprint); err != nil {
+		conn.Write([]byte(fmt.Sprintf("Could not op %s: %s\n", target, err)))
+		return
+	}
+
+	victim.write("You have been granted admin privileges.\n")
+	conn.Write([]byte(fmt.Sprintf("%s is now an admin.\n", target)))
+}
+
+func sendUsersList(conn Conn) {
+	mutex.Lock()
+	snapshot := make([]*User, 0, len(onlineUsers))
+	for _, user := range onlineUsers {
+		snapshot = append(snapshot, user)
+	}
+	mutex.Unlock()
+
+	var usersList []string
+	for _, user := range snapshot {
+		user.mu.Lock()
+		usersList = append(usersList, user.Nickname)
+		user.mu.Unlock()
+	}
+
+	message := fmt.Sprintf("Connected users: %s\n", strings.Join(usersList, ", "))
+	conn.Write([]byte(message))
+}
+
+func changeNickname(conn Conn, nickname *string, newNickname string) {
+	oldNickname := *nickname
+	*nickname = newNickname
+
+	user, ok := userOf(conn)
+	if !ok {
+		return
+	}
+	user.mu.Lock()
+	user.Nickname = newNickname
+	fingerprint := user.Fingerprint
+	user.mu.Unlock()
+
+	if fingerprint != "" {
+		if err := users.Register(fingerprint, newNickname); err != nil {
+			log.Printf("Error persisting nickname for %s: %s", fingerprint, err)
+		}
+	}
+
+	conn.Write([]byte(fmt.Sprintf("Nickname changed to %s\n", newNickname)))
+
+	log.Printf("Client %s (%s) changed nickname to %s.", conn.RemoteAddr(), oldNickname, newNickname)
+	broadcastMessage(fmt.Sprintf("'%s' changed nickname to '%s'\n", oldNickname, newNickname), conn)
+}
+
+// attachUser implements "/attach [seq]": replay every broadcast the
+// user's Ring has buffered since seq (0 replays everything still
+// buffered), so a client that lost its connection can catch up
+// instead of missing what was sent while it was away.
+func attachUser(conn Conn, arg string) {
+	user, ok := userOf(conn)
+	if !ok {
+		conn.Write([]byte("Not attached to any user.\n"))
+		return
+	}
+
+	var since uint64
+	if arg != "" {
+		parsed, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			conn.Write([]byte(fmt.Sprintf("Invalid sequence number: %s\n", arg)))
+			return
+		}
+		since = parsed
+	}
+
+	lines, next := user.Ring.Since(since)
+	for _, line := range lines {
+		conn.Write([]byte(line))
+	}
+	conn.Write([]byte(fmt.Sprintf("Attached. Seq: %d\n", next)))
+}
+
+// sendHistory implements "/history [since <cursor>]". With no
+// argument it replays a channel's log from the start; with "since
+// <cursor>" it resumes exactly where the client left off. Either way
+// it ends with the cursor the client should send next time.
+func sendHistory(conn Conn, args []string) {
+	var cursor history.Cursor
+	switch {
+	case len(args) >= 2 && args[0] == "since":
+		parsed, err := history.ParseCursor(args[1])
+		if err != nil {
+			conn.Write([]byte(fmt.Sprintf("Invalid cursor: %s\n", err)))
+			return
+		}
+		cursor = parsed
+	case len(args) == 1:
+		cursor = history.Cursor{Channel: args[0]}
+	default:
+		cursor = history.Cursor{Channel: globalChannel}
+	}
+
+	lines, next, err := histories.Since(cursor)
+	if err != nil {
+		log.Printf("Error reading history: %s", err)
+		conn.Write([]byte("Error reading history.\n"))
+		return
+	}
+
+	for _, line := range lines {
+		if _, err := conn.Write([]byte(line + "\n")); err != nil {
+			log.Printf("Error sending history to client: %s", err)
+			return
+		}
+	}
+	conn.Write([]byte(fmt.Sprintf("Cursor: %s\n", next)))
+}
+
+// subscribeChannel implements "/subscribe <channel>": from now on,
+// every message appended to the channel's log is pushed to conn as it
+// happens, on top of whatever /history replay the client already did.
+func subscribeChannel(conn Conn, channel string) {
+	err := watcher.Subscribe(channel, func(line string) {
+		conn.Write([]byte(line + "\n"))
+	})
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("Could not subscribe to %s: %s\n", channel, err)))
+		return
+	}
+	conn.Write([]byte(fmt.Sprintf("Subscribed to %s\n", channel)))
+}
+
+func logMessage(nickname, message string) {
+	currentTime := time.Now().Format(time.RFC1123)
+	logEntry := fmt.Sprintf("%s: %s - %s", currentTime, nickname, message)
+	if _, err := histories.Append(globalChannel, logEntry); err != nil {
+		log.Printf("Error appending to history: %s", err)
+	}
+}
+
+// broadcastMessage fans a message out to every known user rather than
+// writing straight to net.Conn: each user gets the message appended to
+// its Ring (so it's there to replay on /attach) and delivered to all
+// of its live sessions except sender.
+func broadcastMessage(message string, sender Conn) {
+	mutex.Lock()
+	snapshot := make([]*User, 0, len(onlineUsers))
+	for _, user := range onlineUsers {
+		snapshot = append(snapshot, user)
+	}
+	mutex.Unlock()
+
+	for _, user := range snapshot {
+		user.writeExcept(message, sender)
+	}
+}
+
+// remoteHost strips the port off a net.Addr's string form so bans can
+// target an IP regardless of the ephemeral source port.
+func remoteHost(a net.Addr) string {
+	host, _, err := net.SplitHostPort(a.String())
+	if err != nil {
+		return a.String()
+	}
+	return host
+}
+
+func main() {
+	usersPath := flag.String("users", "users.json", "path to the fingerprint->nickname store")
+	adminsPath := flag.String("admins", "admins.json", "path to the admin fingerprint list")
+	hostKeyPath := flag.String("host-key", "host_key", "path to the SSH host private key")
+	sshPort := flag.Int("ssh-port", 2222, "port to listen for SSH connections on")
+	protocol := flag.String("protocol", "simple", "wire protocol to speak: simple|irc")
+	historyDir := flag.String("history-dir", "logs", "directory to store per-channel history logs in")
+	tasksDB := flag.String("tasks-db", "tasks.db", "path to the SQLite database for tasks")
+	bounceTTL := flag.Duration("bounce-ttl", 10*time.Minute, "how long a disconnected user's ring buffer is kept for /attach")
+	writeTimeoutFlag := flag.Duration("write-timeout", 5*time.Second, "max time a write to a client may block before it is dropped")
+	flag.Parse()
+
+	writeTimeout = *writeTimeoutFlag
+
+	if *protocol != "simple" && *protocol != "irc" {
+		log.Fatalf("Invalid -protocol %q, want simple or irc", *protocol)
+	}
+	protocolMode = *protocol
+
+	var err error
+	users, err = auth.LoadUserStore(*usersPath)
+	if err != nil {
+		log.Fatal("Error loading user store:", err)
+	}
+	admins, err = auth.LoadAdminSet(*adminsPath)
+	if err != nil {
+		log.Fatal("Error loading admin config:", err)
+	}
+	histories, err = history.NewStore(*historyDir)
+	if err != nil {
+		log.Fatal("Error opening history store:", err)
+	}
+	watcher, err = history.NewWatcher(histories)
+	if err != nil {
+		log.Fatal("Error starting history watcher:", err)
+	}
+	taskStore, err = storage.Open(*tasksDB)
+	if err != nil {
+		log.Fatal("Error opening task store:", err)
+	}
+	defer taskStore.Close()
+
+	go reapDetachedUsers(*bounceTTL)
+	go serveSSH(*sshPort, *hostKeyPath)
+
+	listener, err := net.Listen(CONN_TYPE, CONN_PORT)
+	if err != nil {
+		log.Fatal("Error starting TCP server:", err)
+	}
+	defer listener.Close()
+	log.Println("Server listening on", CONN_PORT)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Println("Error accepting connection:", err)
+			continue
+		}
+		if bans.Banned(remoteHost(conn.RemoteAddr())) {
+			conn.Write([]byte("You are banned from this server.\n"))
+			conn.Close()
+			continue
+		}
+		go handleConnection(newSafeConn(conn, writeTimeout), "")
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshChannelConn adapts an ssh.Channel (which has no notion of a
+// remote address) into a Conn by pairing it with the underlying
+// connection's address, so handleConnection can treat SSH and raw TCP
+// clients identically.
+type sshChannelConn struct {
+	ssh.Channel
+	remote net.Addr
+}
+
+func (c sshChannelConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// serveSSH listens for SSH connections on port, identifying each
+// client by the fingerprint of the public key it authenticates with.
+// Any key is accepted; the fingerprint is what grants identity and,
+// via admins.json, the admin role.
+func serveSSH(port int, hostKeyPath string) {
+	signer, err := loadOrCreateHostKey(hostKeyPath)
+	if err != nil {
+		log.Fatal("Error loading SSH host key:", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			fingerprint := ssh.FingerprintSHA256(key)
+			if bans.Banned(fingerprint) || bans.Banned(remoteHost(conn.RemoteAddr())) {
+				return nil, fmt.Errorf("banned")
+			}
+			return &ssh.Permissions{
+				Extensions: map[string]string{"fingerprint": fingerprint},
+			}, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		log.Fatal("Error starting SSH server:", err)
+	}
+	log.Println("Server listening for SSH on", listener.Addr())
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Println("Error accepting SSH connection:", err)
+			continue
+		}
+		go handleSSHConn(conn, config)
+	}
+}
+
+func handleSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		log.Println("SSH handshake failed:", err)
+		conn.Close()
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	fingerprint := sconn.Permissions.Extensions["fingerprint"]
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			log.Println("Error accepting SSH channel:", err)
+			continue
+		}
+
+		// Chat happens over the raw channel as soon as it's open; we
+		// don't provide a real shell, so just ack pty/shell requests
+		// and otherwise ignore them.
+		go func() {
+			for req := range requests {
+				switch req.Type {
+				case "shell", "pty-req":
+					req.Reply(true, nil)
+				default:
+					if req.WantReply {
+						req.Reply(false, nil)
+					}
+				}
+			}
+		}()
+
+		sessionConn := sshChannelConn{Channel: channel, remote: sconn.RemoteAddr()}
+		handleConnection(newSafeConn(sessionConn, writeTimeout), fingerprint)
+	}
+}
+
+// loadOrCreateHostKey loads an SSH host key from path, generating and
+// persisting a fresh ed25519 key the first time the server runs.
+func loadOrCreateHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+
+	return ssh.NewSignerFromKey(priv)
+}
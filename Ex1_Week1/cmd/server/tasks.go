@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IlyaShelestov/Ex1_Week1/storage"
+)
+
+// tasksChannel is the history channel task lifecycle events are
+// appended to, so anyone who runs "/subscribe tasks" sees them live.
+const tasksChannel = "tasks"
+
+// handleTaskCommand implements the "/task ..." family: add, assign,
+// status, list, due, show and delete.
+func handleTaskCommand(conn Conn, nickname string, args []string) {
+	if len(args) == 0 {
+		conn.Write([]byte("Usage: /task <add|assign|status|list|due|show|delete> ...\n"))
+		return
+	}
+
+	sub, rest := args[0], args[1:]
+	switch sub {
+	case "add":
+		if len(rest) == 0 {
+			conn.Write([]byte("Usage: /task add <description>\n"))
+			return
+		}
+		taskAdd(conn, nickname, strings.Join(rest, " "))
+	case "assign":
+		if len(rest) != 2 {
+			conn.Write([]byte("Usage: /task assign <id> <nick>\n"))
+			return
+		}
+		taskAssign(conn, rest[0], rest[1])
+	case "status":
+		if len(rest) != 2 {
+			conn.Write([]byte("Usage: /task status <id> <open|doing|done>\n"))
+			return
+		}
+		taskSetStatus(conn, rest[0], rest[1])
+	case "list":
+		taskList(conn, nickname, rest)
+	case "due":
+		if len(rest) != 2 {
+			conn.Write([]byte("Usage: /task due <id> <RFC3339>\n"))
+			return
+		}
+		taskSetDue(conn, rest[0], rest[1])
+	case "show":
+		if len(rest) != 1 {
+			conn.Write([]byte("Usage: /task show <id>\n"))
+			return
+		}
+		taskShow(conn, rest[0])
+	case "delete":
+		if len(rest) != 1 {
+			conn.Write([]byte("Usage: /task delete <id>\n"))
+			return
+		}
+		taskDelete(conn, rest[0])
+	default:
+		conn.Write([]byte(fmt.Sprintf("Unknown /task subcommand: %s\n", sub)))
+	}
+}
+
+func parseTaskID(conn Conn, arg string) (int64, bool) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("Invalid task id: %s\n", arg)))
+		return 0, false
+	}
+	return id, true
+}
+
+func taskAdd(conn Conn, owner, description string) {
+	task, err := taskStore.AddTask(description, owner)
+	if err != nil {
+		log.Println("Error adding task:", err)
+		conn.Write([]byte("Error adding task.\n"))
+		return
+	}
+
+	conn.Write([]byte(fmt.Sprintf("Task added with ID %d\n", task.ID)))
+	announceTask("added", task)
+}
+
+func taskAssign(conn Conn, idArg, assignee string) {
+	id, ok := parseTaskID(conn, idArg)
+	if !ok {
+		return
+	}
+
+	task, err := taskStore.Assign(id, assignee)
+	if err != nil {
+		log.Println("Error assigning task:", err)
+		conn.Write([]byte("Error assigning task.\n"))
+		return
+	}
+
+	conn.Write([]byte(fmt.Sprintf("Task %d assigned to %s\n", task.ID, assignee)))
+	announceTask("assigned", task)
+}
+
+func taskSetStatus(conn Conn, idArg, status string) {
+	id, ok := parseTaskID(conn, idArg)
+	if !ok {
+		return
+	}
+
+	task, err := taskStore.SetStatus(id, status)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("Error updating status: %s\n", err)))
+		return
+	}
+
+	conn.Write([]byte(fmt.Sprintf("Task %d is now %s\n", task.ID, task.Status)))
+	announceTask("status changed", task)
+}
+
+func taskSetDue(conn Conn, idArg, dueArg string) {
+	id, ok := parseTaskID(conn, idArg)
+	if !ok {
+		return
+	}
+
+	due, err := time.Parse(time.RFC3339, dueArg)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("Invalid due date %q, want RFC3339\n", dueArg)))
+		return
+	}
+
+	task, err := taskStore.SetDue(id, due)
+	if err != nil {
+		log.Println("Error setting task due date:", err)
+		conn.Write([]byte("Error setting due date.\n"))
+		return
+	}
+
+	conn.Write([]byte(fmt.Sprintf("Task %d due %s\n", task.ID, due.Format(time.RFC3339))))
+	announceTask("due date set", task)
+}
+
+// taskList implements "/task list [mine|@nick|status:open]".
+func taskList(conn Conn, nickname string, args []string) {
+	var filter storage.Filter
+	if len(args) > 0 {
+		switch arg := args[0]; {
+		case arg == "mine":
+			filter.Owner = nickname
+		case strings.HasPrefix(arg, "@"):
+			filter.Assignee = strings.TrimPrefix(arg, "@")
+		case strings.HasPrefix(arg, "status:"):
+			filter.Status = strings.TrimPrefix(arg, "status:")
+		default:
+			conn.Write([]byte(fmt.Sprintf("Unknown /task list filter: %s\n", arg)))
+			return
+		}
+	}
+
+	tasks, err := taskStore.List(filter)
+	if err != nil {
+		log.Println("Error listing tasks:", err)
+		conn.Write([]byte("Error listing tasks.\n"))
+		return
+	}
+
+	if len(tasks) == 0 {
+		conn.Write([]byte("No tasks found.\n"))
+		return
+	}
+
+	var lines []string
+	for _, task := range tasks {
+		lines = append(lines, formatTaskSummary(task))
+	}
+	conn.Write([]byte(strings.Join(lines, "; ") + "\n"))
+}
+
+func taskShow(conn Conn, idArg string) {
+	id, ok := parseTaskID(conn, idArg)
+	if !ok {
+		return
+	}
+
+	task, err := taskStore.Get(id)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("Task %d not found.\n", id)))
+		return
+	}
+
+	due := "none"
+	if task.DueAt != nil {
+		due = task.DueAt.Format(time.RFC3339)
+	}
+	conn.Write([]byte(fmt.Sprintf(
+		"Task %d: %s\nOwner: %s\nAssignee: %s\nStatus: %s\nDue: %s\nCreated: %s\nUpdated: %s\n",
+		task.ID, task.Description, task.Owner, task.Assignee, task.Status, due,
+		task.CreatedAt.Format(time.RFC3339), task.UpdatedAt.Format(time.RFC3339),
+	)))
+}
+
+func taskDelete(conn Conn, idArg string) {
+	id, ok := parseTaskID(conn, idArg)
+	if !ok {
+		return
+	}
+
+	existed, err := taskStore.Delete(id)
+	if err != nil {
+		log.Println("Error deleting task:", err)
+		conn.Write([]byte("Error deleting task.\n"))
+		return
+	}
+	if !existed {
+		conn.Write([]byte(fmt.Sprintf("Task %d not found.\n", id)))
+		return
+	}
+
+	conn.Write([]byte("Task deleted successfully.\n"))
+}
+
+func formatTaskSummary(task storage.Task) string {
+	return fmt.Sprintf("ID: %d, Owner: %s, Assignee: %s, Status: %s, Description: %s",
+		task.ID, task.Owner, task.Assignee, task.Status, task.Description)
+}
+
+// announceTask records a task lifecycle event to the tasks channel's
+// history, so it reaches whoever has /subscribe'd to it, rather than
+// broadcasting to every connected user regardless of interest.
+func announceTask(event string, task storage.Task) {
+	line := fmt.Sprintf("Task %d %s: %s", task.ID, event, task.Description)
+	if _, err := histories.Append(tasksChannel, line); err != nil {
+		log.Println("Error recording task event:", err)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IlyaShelestov/Ex1_Week1/bouncer"
+)
+
+// ringCapacity bounds how many broadcast messages a user's Ring keeps
+// for replay after all of their sessions disconnect.
+const ringCapacity = 200
+
+// Session is one live connection belonging to a User. A user can have
+// several at once (e.g. a phone and a laptop both attached).
+type Session struct {
+	conn Conn
+	user *User
+}
+
+// User is a chat identity: one or more live Sessions sharing a
+// nickname and a Ring that keeps buffering broadcasts even while the
+// user has no session attached, so a reconnect can replay what it
+// missed instead of losing it.
+type User struct {
+	mu          sync.Mutex
+	Nickname    string
+	Fingerprint string
+	Sessions    []*Session
+	Ring        *bouncer.Ring
+	detachedAt  time.Time // zero while at least one session is attached
+}
+
+var (
+	// onlineUsers holds one User per identity (an SSH fingerprint, or
+	// a synthetic per-connection id for unauthenticated clients), kept
+	// alive for ringTTL after the last session detaches.
+	onlineUsers = make(map[string]*User)
+	// sessionByConn finds the Session (and thus User) a given
+	// connection belongs to, without scanning onlineUsers.
+	sessionByConn = make(map[Conn]*Session)
+)
+
+// identityFor returns the onlineUsers key for a connection: its SSH
+// fingerprint if it authenticated with one, otherwise a synthetic id
+// that is unique to this connection (and so never bounces, since an
+// unauthenticated client has no stable identity to bounce to).
+func identityFor(conn Conn, fingerprint string) string {
+	if fingerprint != "" {
+		return fingerprint
+	}
+	return fmt.Sprintf("anon:%p", conn)
+}
+
+// attachSession finds or creates the User for identity and attaches a
+// new Session for conn to it, registering the session for lookup.
+func attachSession(conn Conn, fingerprint, nickname string) *Session {
+	identity := identityFor(conn, fingerprint)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	user, ok := onlineUsers[identity]
+	if !ok {
+		user = &User{Nickname: nickname, Fingerprint: fingerprint, Ring: bouncer.NewRing(ringCapacity)}
+		onlineUsers[identity] = user
+	}
+
+	session := &Session{conn: conn, user: user}
+
+	user.mu.Lock()
+	user.Sessions = append(user.Sessions, session)
+	user.detachedAt = time.Time{}
+	user.mu.Unlock()
+
+	sessionByConn[conn] = session
+	return session
+}
+
+// detachSession removes conn's session from its user. Once a user has
+// no sessions left, its Ring is kept around (for ringTTL, reaped by
+// reapDetachedUsers) so a reconnect can still replay from it.
+func detachSession(conn Conn) {
+	mutex.Lock()
+	session, ok := sessionByConn[conn]
+	delete(sessionByConn, conn)
+	mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	user := session.user
+	user.mu.Lock()
+	for i, s := range user.Sessions {
+		if s == session {
+			user.Sessions = append(user.Sessions[:i], user.Sessions[i+1:]...)
+			break
+		}
+	}
+	if len(user.Sessions) == 0 {
+		user.detachedAt = time.Now()
+	}
+	user.mu.Unlock()
+}
+
+// reapDetachedUsers runs forever, dropping users whose last session
+// detached more than ttl ago so memory doesn't grow without bound.
+func reapDetachedUsers(ttl time.Duration) {
+	for range time.Tick(time.Minute) {
+		mutex.Lock()
+		for identity, user := range onlineUsers {
+			user.mu.Lock()
+			expired := len(user.Sessions) == 0 && !user.detachedAt.IsZero() && time.Since(user.detachedAt) > ttl
+			user.mu.Unlock()
+			if expired {
+				delete(onlineUsers, identity)
+			}
+		}
+		mutex.Unlock()
+	}
+}
+
+// userOf returns the User a connection belongs to, if it has an
+// active session.
+func userOf(conn Conn) (*User, bool) {
+	mutex.Lock()
+	session, ok := sessionByConn[conn]
+	mutex.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return session.user, true
+}
+
+// findUserByNickname looks up a User by its current nickname.
+func findUserByNickname(nickname string) (*User, bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, user := range onlineUsers {
+		user.mu.Lock()
+		nick := user.Nickname
+		user.mu.Unlock()
+		if nick == nickname {
+			return user, true
+		}
+	}
+	return nil, false
+}
+
+// sessions returns a snapshot of u's current sessions, safe to use
+// without holding u.mu.
+func (u *User) sessions() []*Session {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]*Session(nil), u.Sessions...)
+}
+
+// write sends line to every session of u and records it in the user's
+// Ring, so it's there to replay if the user later reconnects.
+func (u *User) write(line string) {
+	u.Ring.Push(line)
+	for _, s := range u.sessions() {
+		s.conn.Write([]byte(line))
+	}
+}
+
+// writeExcept is like write but skips a given connection (typically
+// the sender, which already has its own copy of the message).
+func (u *User) writeExcept(line string, except Conn) {
+	u.Ring.Push(line)
+	for _, s := range u.sessions() {
+		if s.conn != except {
+			s.conn.Write([]byte(line))
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Package history stores chat messages in an append-only, per-channel
+// log directory and lets clients resume reading from a cursor instead
+// of replaying the whole log on every reconnect.
+package history
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cursor identifies a position in a channel's log: the file the
+// client last read from and the line number within it. It serializes
+// to the "<channel>/<filename>/<lineno>" form clients pass to
+// "/history since <cursor>".
+type Cursor struct {
+	Channel string
+	File    string
+	Line    int
+}
+
+// String renders c in the wire format clients send back to resume.
+func (c Cursor) String() string {
+	return fmt.Sprintf("%s/%s/%d", c.Channel, c.File, c.Line)
+}
+
+// ParseCursor parses the "<channel>/<filename>/<lineno>" form produced
+// by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	parts := strings.SplitN(s, "/", 3)
+	if len(parts) != 3 {
+		return Cursor{}, fmt.Errorf("history: malformed cursor %q", s)
+	}
+
+	line, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return Cursor{}, fmt.Errorf("history: malformed cursor %q: %w", s, err)
+	}
+
+	return Cursor{Channel: parts[0], File: parts[1], Line: line}, nil
+}
+
+// Store is an append-only log of chat messages, split per channel and
+// per day so no single file grows without bound.
+type Store struct {
+	mutex sync.Mutex
+	dir   string
+}
+
+// NewStore returns a Store rooted at dir, creating it if needed.
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("history: creating %s: %w", dir, err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) channelDir(channel string) string {
+	return filepath.Join(s.dir, channel)
+}
+
+func (s *Store) todayFile(channel string) string {
+	return time.Now().Format("2006-01-02") + ".log"
+}
+
+// Append writes line to channel's log for today and returns the
+// cursor pointing just past it.
+func (s *Store) Append(channel, line string) (Cursor, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	dir := s.channelDir(channel)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Cursor{}, fmt.Errorf("history: creating %s: %w", dir, err)
+	}
+
+	name := s.todayFile(channel)
+	path := filepath.Join(dir, name)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("history: opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		return Cursor{}, fmt.Errorf("history: writing %s: %w", path, err)
+	}
+
+	lineno, err := countLines(path)
+	if err != nil {
+		return Cursor{}, err
+	}
+
+	return Cursor{Channel: channel, File: name, Line: lineno}, nil
+}
+
+func countLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		count++
+	}
+	return count, scanner.Err()
+}
+
+// logFiles lists a channel's log files in chronological order. The
+// YYYY-MM-DD.log naming scheme sorts correctly as plain strings.
+func (s *Store) logFiles(channel string) ([]string, error) {
+	entries, err := os.ReadDir(s.channelDir(channel))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("history: listing %s: %w", channel, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Since returns every line appended to cursor.Channel after cursor,
+// along with the cursor a client should send next time to resume
+// exactly where this call left off. A zero-value cursor (empty File)
+// means "from the beginning of the channel's history".
+func (s *Store) Since(cursor Cursor) ([]string, Cursor, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	files, err := s.logFiles(cursor.Channel)
+	if err != nil {
+		return nil, cursor, err
+	}
+	if len(files) == 0 {
+		return nil, cursor, nil
+	}
+
+	startIdx := 0
+	if cursor.File != "" {
+		for i, name := range files {
+			if name == cursor.File {
+				startIdx = i
+				break
+			}
+		}
+	}
+
+	var lines []string
+	next := cursor
+	for i := startIdx; i < len(files); i++ {
+		name := files[i]
+		skip := 0
+		if name == cursor.File {
+			skip = cursor.Line
+		}
+
+		path := filepath.Join(s.channelDir(cursor.Channel), name)
+		fileLines, err := readLinesAfter(path, skip)
+		if err != nil {
+			return nil, cursor, err
+		}
+
+		lines = append(lines, fileLines...)
+		next = Cursor{Channel: cursor.Channel, File: name, Line: skip + len(fileLines)}
+	}
+
+	return lines, next, nil
+}
+
+func readLinesAfter(path string, skip int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("history: opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var lines []string
+	lineno := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineno++
+		if lineno <= skip {
+			continue
+		}
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
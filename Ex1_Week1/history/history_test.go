@@ -0,0 +1,142 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCursorStringParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Cursor
+	}{
+		{"basic", Cursor{Channel: "general", File: "2026-07-27.log", Line: 3}},
+		{"zero line", Cursor{Channel: "tasks", File: "2026-07-27.log", Line: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.c.String()
+			got, err := ParseCursor(s)
+			if err != nil {
+				t.Fatalf("ParseCursor(%q) error: %v", s, err)
+			}
+			if got != tt.c {
+				t.Fatalf("ParseCursor(%q) = %+v, want %+v", s, got, tt.c)
+			}
+		})
+	}
+}
+
+func TestParseCursorMalformed(t *testing.T) {
+	tests := []string{
+		"",
+		"general",
+		"general/2026-07-27.log",
+		"general/2026-07-27.log/notanumber",
+	}
+
+	for _, s := range tests {
+		if _, err := ParseCursor(s); err == nil {
+			t.Errorf("ParseCursor(%q) = nil error, want error", s)
+		}
+	}
+}
+
+func TestStoreSinceAcrossFileBoundary(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	// Write two days' worth of log files directly, bypassing Append
+	// (which always targets today's file), so Since has to cross a
+	// file boundary within a single call.
+	channelDir := filepath.Join(dir, "general")
+	if err := os.MkdirAll(channelDir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(channelDir, "2026-07-26.log"), []byte("alice: hi\nbob: hey\n"), 0644); err != nil {
+		t.Fatalf("WriteFile day1: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(channelDir, "2026-07-27.log"), []byte("alice: morning\n"), 0644); err != nil {
+		t.Fatalf("WriteFile day2: %v", err)
+	}
+
+	// Cursor points at the last line of day1; Since must pick up the
+	// rest of day1 (nothing) plus all of day2.
+	cursor := Cursor{Channel: "general", File: "2026-07-26.log", Line: 2}
+	lines, next, err := store.Since(cursor)
+	if err != nil {
+		t.Fatalf("Since: %v", err)
+	}
+	if !reflect.DeepEqual(lines, []string{"alice: morning"}) {
+		t.Fatalf("Since across file boundary lines = %v, want [alice: morning]", lines)
+	}
+	want := Cursor{Channel: "general", File: "2026-07-27.log", Line: 1}
+	if next != want {
+		t.Fatalf("Since across file boundary next = %+v, want %+v", next, want)
+	}
+
+	// A cursor from the very beginning should return every line from
+	// both files in order.
+	lines, _, err = store.Since(Cursor{Channel: "general"})
+	if err != nil {
+		t.Fatalf("Since from start: %v", err)
+	}
+	wantAll := []string{"alice: hi", "bob: hey", "alice: morning"}
+	if !reflect.DeepEqual(lines, wantAll) {
+		t.Fatalf("Since from start lines = %v, want %v", lines, wantAll)
+	}
+}
+
+func TestStoreSinceUnknownChannel(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	lines, next, err := store.Since(Cursor{Channel: "nope"})
+	if err != nil {
+		t.Fatalf("Since: %v", err)
+	}
+	if lines != nil {
+		t.Fatalf("Since on unknown channel lines = %v, want nil", lines)
+	}
+	if next != (Cursor{Channel: "nope"}) {
+		t.Fatalf("Since on unknown channel next = %+v, want unchanged cursor", next)
+	}
+}
+
+func TestStoreAppendThenSince(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	if _, err := store.Append("general", "alice: hi"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	cursor, err := store.Append("general", "bob: hey")
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if cursor.Line != 2 {
+		t.Fatalf("cursor after second append = %+v, want Line 2", cursor)
+	}
+
+	if _, err := store.Append("general", "alice: you there?"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	lines, _, err := store.Since(cursor)
+	if err != nil {
+		t.Fatalf("Since: %v", err)
+	}
+	if !reflect.DeepEqual(lines, []string{"alice: you there?"}) {
+		t.Fatalf("Since(cursor) lines = %v, want [alice: you there?]", lines)
+	}
+}
@@ -0,0 +1,116 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Subscriber receives lines appended to a channel after it subscribed,
+// turning the log into a live event stream rather than a one-shot
+// dump. It mirrors the write path of the net.Conn the caller
+// subscribed on.
+type Subscriber func(line string)
+
+// Watcher pushes newly appended lines to subscribers of a channel via
+// fsnotify, independent of the polling done by Since.
+type Watcher struct {
+	store *Store
+
+	mutex       sync.Mutex
+	watcher     *fsnotify.Watcher
+	subscribers map[string][]Subscriber // channel -> subscribers
+	cursors     map[string]Cursor       // channel -> last position pushed
+	dirs        map[string]string       // watched directory -> channel
+}
+
+// NewWatcher starts an fsnotify watch over store's log directory.
+func NewWatcher(store *Store) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		store:       store,
+		watcher:     fsw,
+		subscribers: make(map[string][]Subscriber),
+		cursors:     make(map[string]Cursor),
+		dirs:        make(map[string]string),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Subscribe registers fn to be called with each line appended to
+// channel from now on, creating the channel's log directory (and
+// watching it) if this is the first subscriber.
+func (w *Watcher) Subscribe(channel string, fn Subscriber) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, ok := w.subscribers[channel]; !ok {
+		dir := w.store.channelDir(channel)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			return err
+		}
+		w.dirs[dir] = channel
+
+		// Start from the current end of the log so subscribers only
+		// see messages sent after they subscribed.
+		_, cursor, err := w.store.Since(Cursor{Channel: channel})
+		if err != nil {
+			return err
+		}
+		w.cursors[channel] = cursor
+	}
+
+	w.subscribers[channel] = append(w.subscribers[channel], fn)
+	return nil
+}
+
+func (w *Watcher) run() {
+	for event := range w.watcher.Events {
+		if event.Op&fsnotify.Write == 0 {
+			continue
+		}
+
+		w.mutex.Lock()
+		channel, ok := w.dirs[filepath.Dir(event.Name)]
+		w.mutex.Unlock()
+		if !ok {
+			continue
+		}
+		w.deliver(channel)
+	}
+}
+
+func (w *Watcher) deliver(channel string) {
+	w.mutex.Lock()
+	cursor, ok := w.cursors[channel]
+	subscribers := w.subscribers[channel]
+	w.mutex.Unlock()
+	if !ok || len(subscribers) == 0 {
+		return
+	}
+
+	lines, next, err := w.store.Since(cursor)
+	if err != nil || len(lines) == 0 {
+		return
+	}
+
+	w.mutex.Lock()
+	w.cursors[channel] = next
+	w.mutex.Unlock()
+
+	for _, line := range lines {
+		for _, fn := range subscribers {
+			fn(line)
+		}
+	}
+}
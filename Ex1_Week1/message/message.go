@@ -0,0 +1,114 @@
+// Package message parses and renders lines in the IRC wire format
+// (RFC 1459/2812), so the server can talk to standard IRC clients.
+package message
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Message is a single parsed IRC line: an optional prefix, a command
+// (a name like "PRIVMSG" or a three-digit numeric), and its
+// parameters. Trailing holds the last parameter when it was sent in
+// ":trailing" form; it is also the last entry of Params for
+// convenience.
+type Message struct {
+	Prefix   string
+	Command  string
+	Params   []string
+	Trailing string
+}
+
+// Parse parses a single IRC line of the form
+//
+//	[:prefix] COMMAND param0 param1 ... [:trailing]
+//
+// into a Message. It returns an error if the line has no command.
+func Parse(line string) (Message, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return Message{}, fmt.Errorf("message: empty line")
+	}
+
+	var msg Message
+
+	if strings.HasPrefix(line, ":") {
+		parts := strings.SplitN(line[1:], " ", 2)
+		msg.Prefix = parts[0]
+		if len(parts) == 1 {
+			return Message{}, fmt.Errorf("message: prefix with no command")
+		}
+		line = parts[1]
+	}
+
+	trailing := ""
+	if idx := strings.Index(line, " :"); idx != -1 {
+		trailing = line[idx+2:]
+		line = line[:idx]
+	} else if strings.HasPrefix(line, ":") {
+		trailing = line[1:]
+		line = ""
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return Message{}, fmt.Errorf("message: no command")
+	}
+
+	msg.Command = strings.ToUpper(fields[0])
+	msg.Params = fields[1:]
+	if trailing != "" || strings.HasSuffix(strings.TrimRight(line, " "), " :") {
+		msg.Params = append(msg.Params, trailing)
+	}
+	msg.Trailing = trailing
+
+	return msg, nil
+}
+
+// String renders msg back into IRC wire format.
+func (m Message) String() string {
+	var b strings.Builder
+	if m.Prefix != "" {
+		b.WriteString(":")
+		b.WriteString(m.Prefix)
+		b.WriteString(" ")
+	}
+	b.WriteString(m.Command)
+
+	for i, param := range m.Params {
+		b.WriteString(" ")
+		last := i == len(m.Params)-1
+		if last && (strings.Contains(param, " ") || strings.HasPrefix(param, ":") || param == "") {
+			b.WriteString(":")
+		}
+		b.WriteString(param)
+	}
+
+	return b.String()
+}
+
+// SplitTarget splits an IRC source of the form "nick!user@host" into
+// its three components. Any component may come back empty if the
+// source doesn't include it (e.g. a bare server name).
+func SplitTarget(source string) (nick, user, host string) {
+	if at := strings.Index(source, "@"); at != -1 {
+		host = source[at+1:]
+		source = source[:at]
+	}
+	if bang := strings.Index(source, "!"); bang != -1 {
+		user = source[bang+1:]
+		source = source[:bang]
+	}
+	nick = source
+	return nick, user, host
+}
+
+// channelPrefixes are the characters RFC 2811 allows to start a
+// channel name.
+const channelPrefixes = "#&+!"
+
+// IsChannel reports whether target names a channel rather than a
+// user, per RFC 2811's channel prefix characters.
+func IsChannel(target string) bool {
+	return len(target) > 0 && strings.ContainsRune(channelPrefixes, rune(target[0]))
+}
@@ -0,0 +1,107 @@
+package message
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want Message
+	}{
+		{
+			name: "command only",
+			line: "PING",
+			want: Message{Command: "PING", Params: []string{}},
+		},
+		{
+			name: "command with params",
+			line: "JOIN #test",
+			want: Message{Command: "JOIN", Params: []string{"#test"}},
+		},
+		{
+			name: "prefix and trailing",
+			line: ":alice!alice@host PRIVMSG #test :hello there",
+			want: Message{
+				Prefix:   "alice!alice@host",
+				Command:  "PRIVMSG",
+				Params:   []string{"#test", "hello there"},
+				Trailing: "hello there",
+			},
+		},
+		{
+			name: "lowercase command is uppercased",
+			line: "nick bob",
+			want: Message{Command: "NICK", Params: []string{"bob"}},
+		},
+		{
+			name: "trailing carries a colon of its own",
+			line: "PRIVMSG #test ::)",
+			want: Message{Command: "PRIVMSG", Params: []string{"#test", ":)"}, Trailing: ":)"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(tc.line)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tc.line, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Parse(%q) = %+v, want %+v", tc.line, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{"", ":onlyprefix"}
+	for _, line := range tests {
+		if _, err := Parse(line); err == nil {
+			t.Errorf("Parse(%q) = nil error, want one", line)
+		}
+	}
+}
+
+func TestSplitTarget(t *testing.T) {
+	tests := []struct {
+		source   string
+		nick     string
+		userPart string
+		host     string
+	}{
+		{"alice!alice@example.com", "alice", "alice", "example.com"},
+		{"bob", "bob", "", ""},
+		{"chat-server@host", "chat-server", "", "host"},
+	}
+
+	for _, tc := range tests {
+		nick, user, host := SplitTarget(tc.source)
+		if nick != tc.nick || user != tc.userPart || host != tc.host {
+			t.Errorf("SplitTarget(%q) = (%q, %q, %q), want (%q, %q, %q)",
+				tc.source, nick, user, host, tc.nick, tc.userPart, tc.host)
+		}
+	}
+}
+
+func TestIsChannel(t *testing.T) {
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"#general", true},
+		{"&local", true},
+		{"+modeless", true},
+		{"!safe", true},
+		{"alice", false},
+		{"", false},
+	}
+
+	for _, tc := range tests {
+		if got := IsChannel(tc.target); got != tc.want {
+			t.Errorf("IsChannel(%q) = %v, want %v", tc.target, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package storage persists chat tasks in a SQLite database so they
+// survive a server restart, using modernc.org/sqlite (a pure Go
+// driver, so the binary stays CGo-free).
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Task statuses. Any other value is rejected by SetStatus.
+const (
+	StatusOpen  = "open"
+	StatusDoing = "doing"
+	StatusDone  = "done"
+)
+
+// Task is one row of the tasks table.
+type Task struct {
+	ID          int64
+	Description string
+	Owner       string
+	Assignee    string
+	Status      string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DueAt       *time.Time
+}
+
+// Store is a SQLite-backed task store.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens (creating if necessary) the SQLite database at path and
+// ensures the tasks table exists.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("storage: opening %s: %w", path, err)
+	}
+
+	const schema = `
+	CREATE TABLE IF NOT EXISTS tasks (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		description TEXT NOT NULL,
+		owner       TEXT NOT NULL,
+		assignee    TEXT NOT NULL DEFAULT '',
+		status      TEXT NOT NULL DEFAULT 'open',
+		created_at  TEXT NOT NULL,
+		updated_at  TEXT NOT NULL,
+		due_at      TEXT
+	);`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("storage: creating schema: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// AddTask inserts a new open task and returns it.
+func (s *Store) AddTask(description, owner string) (Task, error) {
+	now := time.Now().UTC()
+	result, err := s.db.Exec(
+		`INSERT INTO tasks (description, owner, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		description, owner, StatusOpen, formatTime(now), formatTime(now),
+	)
+	if err != nil {
+		return Task{}, fmt.Errorf("storage: adding task: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Task{}, fmt.Errorf("storage: adding task: %w", err)
+	}
+	return s.Get(id)
+}
+
+// Get returns a single task by id.
+func (s *Store) Get(id int64) (Task, error) {
+	row := s.db.QueryRow(
+		`SELECT id, description, owner, assignee, status, created_at, updated_at, due_at FROM tasks WHERE id = ?`, id,
+	)
+	return scanTask(row)
+}
+
+// Assign sets a task's assignee.
+func (s *Store) Assign(id int64, assignee string) (Task, error) {
+	if _, err := s.db.Exec(
+		`UPDATE tasks SET assignee = ?, updated_at = ? WHERE id = ?`, assignee, formatTime(time.Now().UTC()), id,
+	); err != nil {
+		return Task{}, fmt.Errorf("storage: assigning task %d: %w", id, err)
+	}
+	return s.Get(id)
+}
+
+// SetStatus sets a task's status; status must be one of the Status*
+// constants.
+func (s *Store) SetStatus(id int64, status string) (Task, error) {
+	switch status {
+	case StatusOpen, StatusDoing, StatusDone:
+	default:
+		return Task{}, fmt.Errorf("storage: invalid status %q", status)
+	}
+
+	if _, err := s.db.Exec(
+		`UPDATE tasks SET status = ?, updated_at = ? WHERE id = ?`, status, formatTime(time.Now().UTC()), id,
+	); err != nil {
+		return Task{}, fmt.Errorf("storage: updating status of task %d: %w", id, err)
+	}
+	return s.Get(id)
+}
+
+// SetDue sets a task's due date.
+func (s *Store) SetDue(id int64, due time.Time) (Task, error) {
+	if _, err := s.db.Exec(
+		`UPDATE tasks SET due_at = ?, updated_at = ? WHERE id = ?`, formatTime(due), formatTime(time.Now().UTC()), id,
+	); err != nil {
+		return Task{}, fmt.Errorf("storage: setting due date of task %d: %w", id, err)
+	}
+	return s.Get(id)
+}
+
+// Delete removes a task, reporting whether it existed.
+func (s *Store) Delete(id int64) (bool, error) {
+	result, err := s.db.Exec(`DELETE FROM tasks WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("storage: deleting task %d: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("storage: deleting task %d: %w", id, err)
+	}
+	return n > 0, nil
+}
+
+// Filter narrows List to a subset of tasks. The zero Filter matches
+// every task.
+type Filter struct {
+	Owner    string // "/task list mine" resolves to the requester's nickname
+	Assignee string // "/task list @nick"
+	Status   string // "/task list status:open"
+}
+
+// List returns tasks matching filter, most recently created first.
+func (s *Store) List(filter Filter) ([]Task, error) {
+	query := `SELECT id, description, owner, assignee, status, created_at, updated_at, due_at FROM tasks WHERE 1=1`
+	var args []any
+
+	if filter.Owner != "" {
+		query += ` AND owner = ?`
+		args = append(args, filter.Owner)
+	}
+	if filter.Assignee != "" {
+		query += ` AND assignee = ?`
+		args = append(args, filter.Assignee)
+	}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	query += ` ORDER BY id DESC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("storage: listing tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTask(row rowScanner) (Task, error) {
+	var (
+		task      Task
+		createdAt string
+		updatedAt string
+		dueAt     sql.NullString
+	)
+
+	if err := row.Scan(&task.ID, &task.Description, &task.Owner, &task.Assignee, &task.Status, &createdAt, &updatedAt, &dueAt); err != nil {
+		if err == sql.ErrNoRows {
+			return Task{}, err
+		}
+		return Task{}, fmt.Errorf("storage: scanning task: %w", err)
+	}
+
+	parsed, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return Task{}, fmt.Errorf("storage: parsing created_at: %w", err)
+	}
+	task.CreatedAt = parsed
+
+	parsed, err = time.Parse(time.RFC3339, updatedAt)
+	if err != nil {
+		return Task{}, fmt.Errorf("storage: parsing updated_at: %w", err)
+	}
+	task.UpdatedAt = parsed
+
+	if dueAt.Valid {
+		due, err := time.Parse(time.RFC3339, dueAt.String)
+		if err != nil {
+			return Task{}, fmt.Errorf("storage: parsing due_at: %w", err)
+		}
+		task.DueAt = &due
+	}
+
+	return task, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
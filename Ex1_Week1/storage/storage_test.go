@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestAddGetTask(t *testing.T) {
+	store := openTestStore(t)
+
+	task, err := store.AddTask("write tests", "alice")
+	if err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+	if task.ID == 0 {
+		t.Fatal("AddTask: got zero ID")
+	}
+	if task.Status != StatusOpen {
+		t.Fatalf("AddTask: status = %q, want %q", task.Status, StatusOpen)
+	}
+
+	got, err := store.Get(task.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != task {
+		t.Fatalf("Get(%d) = %+v, want %+v", task.ID, got, task)
+	}
+}
+
+func TestGetMissing(t *testing.T) {
+	store := openTestStore(t)
+
+	if _, err := store.Get(999); err == nil {
+		t.Fatal("Get on missing task: got nil error, want one")
+	}
+}
+
+func TestAssignSetStatusSetDue(t *testing.T) {
+	store := openTestStore(t)
+
+	task, err := store.AddTask("ship it", "alice")
+	if err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+
+	task, err = store.Assign(task.ID, "bob")
+	if err != nil {
+		t.Fatalf("Assign: %v", err)
+	}
+	if task.Assignee != "bob" {
+		t.Fatalf("Assign: assignee = %q, want bob", task.Assignee)
+	}
+
+	task, err = store.SetStatus(task.ID, StatusDoing)
+	if err != nil {
+		t.Fatalf("SetStatus: %v", err)
+	}
+	if task.Status != StatusDoing {
+		t.Fatalf("SetStatus: status = %q, want %q", task.Status, StatusDoing)
+	}
+
+	if _, err := store.SetStatus(task.ID, "bogus"); err == nil {
+		t.Fatal("SetStatus with invalid status: got nil error, want one")
+	}
+
+	due := time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC)
+	task, err = store.SetDue(task.ID, due)
+	if err != nil {
+		t.Fatalf("SetDue: %v", err)
+	}
+	if task.DueAt == nil || !task.DueAt.Equal(due) {
+		t.Fatalf("SetDue: due = %v, want %v", task.DueAt, due)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	store := openTestStore(t)
+
+	task, err := store.AddTask("throwaway", "alice")
+	if err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+
+	existed, err := store.Delete(task.ID)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if !existed {
+		t.Fatal("Delete: existed = false, want true")
+	}
+
+	existed, err = store.Delete(task.ID)
+	if err != nil {
+		t.Fatalf("Delete (second time): %v", err)
+	}
+	if existed {
+		t.Fatal("Delete (second time): existed = true, want false")
+	}
+}
+
+func TestListFilter(t *testing.T) {
+	store := openTestStore(t)
+
+	mustAdd := func(description, owner string) Task {
+		task, err := store.AddTask(description, owner)
+		if err != nil {
+			t.Fatalf("AddTask: %v", err)
+		}
+		return task
+	}
+
+	t1 := mustAdd("task one", "alice")
+	t2 := mustAdd("task two", "bob")
+	t3 := mustAdd("task three", "alice")
+
+	if _, err := store.Assign(t2.ID, "alice"); err != nil {
+		t.Fatalf("Assign: %v", err)
+	}
+	if _, err := store.SetStatus(t3.ID, StatusDone); err != nil {
+		t.Fatalf("SetStatus: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		wantID []int64
+	}{
+		{"no filter", Filter{}, []int64{t3.ID, t2.ID, t1.ID}},
+		{"by owner", Filter{Owner: "alice"}, []int64{t3.ID, t1.ID}},
+		{"by assignee", Filter{Assignee: "alice"}, []int64{t2.ID}},
+		{"by status", Filter{Status: StatusDone}, []int64{t3.ID}},
+		{"no match", Filter{Owner: "nobody"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, err := store.List(tt.filter)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if len(tasks) != len(tt.wantID) {
+				t.Fatalf("List(%+v) = %d tasks, want %d", tt.filter, len(tasks), len(tt.wantID))
+			}
+			for i, task := range tasks {
+				if task.ID != tt.wantID[i] {
+					t.Fatalf("List(%+v)[%d].ID = %d, want %d", tt.filter, i, task.ID, tt.wantID[i])
+				}
+			}
+		})
+	}
+}